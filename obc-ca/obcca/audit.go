@@ -0,0 +1,347 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// auditLog is a certificate-transparency-style append-only log of every
+// certificate a CA has minted. Leaves are SHA3-384(DER(cert)); internal
+// nodes follow the RFC 6962 Merkle tree hashing rules, so a relying
+// party can verify an inclusion proof against a signed tree head.
+//
+type auditLog struct {
+	mutex sync.Mutex
+
+	file *os.File
+
+	leaves  [][]byte // leaf hashes, in append order
+	serials map[string]int
+
+	dirty bool
+}
+
+// newAuditLog opens (creating if necessary) the append-only log at path
+// and replays it to rebuild the in-memory tree.
+//
+func newAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &auditLog{file: f, serials: make(map[string]int)}
+	if err := log.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	go log.flushPeriodically()
+
+	return log, nil
+}
+
+// replay reads every length-prefixed DER certificate record out of the
+// log file and rebuilds the leaf hashes and serial index from scratch.
+// A partial trailing record left behind by a crash mid-append is
+// dropped by truncating the file back to the end of the last complete
+// record, rather than refusing to start.
+//
+func (log *auditLog) replay() error {
+	offset, err := log.file.Seek(0, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	var lenBuf [4]byte
+	for {
+		recordStart := offset
+
+		n, err := readFull(log.file, lenBuf[:])
+		offset += int64(n)
+		if err != nil {
+			if n == 0 {
+				break
+			}
+			return log.truncateAt(recordStart)
+		}
+
+		der := make([]byte, binary.BigEndian.Uint32(lenBuf[:]))
+		n, err = readFull(log.file, der)
+		offset += int64(n)
+		if err != nil {
+			return log.truncateAt(recordStart)
+		}
+
+		log.indexRecord(der)
+	}
+
+	_, err = log.file.Seek(0, os.SEEK_END)
+
+	return err
+}
+
+// truncateAt drops everything in the log file from offset onward and
+// leaves the write position at the new end, recovering from a partial
+// trailing record.
+//
+func (log *auditLog) truncateAt(offset int64) error {
+	if err := log.file.Truncate(offset); err != nil {
+		return err
+	}
+
+	_, err := log.file.Seek(0, os.SEEK_END)
+
+	return err
+}
+
+func (log *auditLog) indexRecord(der []byte) {
+	index := len(log.leaves)
+	log.leaves = append(log.leaves, rfc6962LeafHash(der))
+
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		log.serials[cert.SerialNumber.String()] = index
+	}
+}
+
+// append adds cert's DER encoding as the next leaf of the log.
+//
+func (log *auditLog) append(der []byte) error {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(der)))
+
+	if _, err := log.file.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	if _, err := log.file.Write(der); err != nil {
+		return err
+	}
+
+	log.indexRecord(der)
+	log.dirty = true
+
+	return nil
+}
+
+// flushPeriodically fsyncs the log file whenever an append is pending,
+// bounding how much of the log could be lost to a crash.
+//
+func (log *auditLog) flushPeriodically() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		log.mutex.Lock()
+		dirty := log.dirty
+		log.dirty = false
+		log.mutex.Unlock()
+
+		if dirty {
+			if err := log.file.Sync(); err != nil {
+				Error.Println(err)
+			}
+		}
+	}
+}
+
+// signedTreeHead returned by sth, matching the RFC 6962 STH fields the
+// TLSCA signs.
+//
+type signedTreeHead struct {
+	TreeSize  int64
+	RootHash  []byte
+	Timestamp int64
+	Sig       []byte
+}
+
+// sth computes and signs the current signed tree head using caPriv.
+//
+func (log *auditLog) sth(caPriv *ecdsa.PrivateKey) (*signedTreeHead, error) {
+	log.mutex.Lock()
+	leaves := append([][]byte(nil), log.leaves...)
+	log.mutex.Unlock()
+
+	root := merkleRoot(leaves)
+	sth := &signedTreeHead{
+		TreeSize:  int64(len(leaves)),
+		RootHash:  root,
+		Timestamp: time.Now().Unix(),
+	}
+
+	hash := sha3.New384()
+	hash.Write(sthSignedPayload(sth))
+
+	r, s, err := ecdsa.Sign(rand.Reader, caPriv, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	sth.Sig = append(r.Bytes(), s.Bytes()...)
+
+	return sth, nil
+}
+
+func sthSignedPayload(sth *signedTreeHead) []byte {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[0:8], uint64(sth.TreeSize))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(sth.Timestamp))
+
+	return append(buf[:], sth.RootHash...)
+}
+
+// inclusionProof returns the RFC 6962 audit path proving that the
+// certificate with the given serial number is included in the first
+// treeSize leaves of the log.
+//
+func (log *auditLog) inclusionProof(serial *big.Int, treeSize int64) ([][]byte, error) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	index, ok := log.serials[serial.String()]
+	if !ok {
+		return nil, errors.New("audit log: serial not found")
+	}
+	if int64(index) >= treeSize || treeSize > int64(len(log.leaves)) {
+		return nil, errors.New("audit log: index out of range for tree size")
+	}
+
+	return merklePath(log.leaves[:treeSize], index), nil
+}
+
+// rfc6962LeafHash returns the RFC 6962 hash of a Merkle tree leaf.
+//
+func rfc6962LeafHash(data []byte) []byte {
+	hash := sha3.New384()
+	hash.Write([]byte{0x00})
+	hash.Write(data)
+
+	return hash.Sum(nil)
+}
+
+// rfc6962NodeHash returns the RFC 6962 hash of an internal Merkle tree
+// node with the given children.
+//
+func rfc6962NodeHash(left, right []byte) []byte {
+	hash := sha3.New384()
+	hash.Write([]byte{0x01})
+	hash.Write(left)
+	hash.Write(right)
+
+	return hash.Sum(nil)
+}
+
+// merkleRoot computes the RFC 6962 Merkle Tree Hash over leaf hashes.
+//
+func merkleRoot(leaves [][]byte) []byte {
+	n := len(leaves)
+	if n == 0 {
+		return sha3.New384().Sum(nil)
+	}
+	if n == 1 {
+		return leaves[0]
+	}
+
+	k := largestPowerOfTwoLessThan(n)
+
+	return rfc6962NodeHash(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+// merklePath computes the RFC 6962 audit path for the leaf at index in
+// leaves.
+//
+func merklePath(leaves [][]byte, index int) [][]byte {
+	n := len(leaves)
+	if n <= 1 {
+		return nil
+	}
+
+	k := largestPowerOfTwoLessThan(n)
+
+	if index < k {
+		return append(merklePath(leaves[:k], index), merkleRoot(leaves[k:]))
+	}
+
+	return append(merklePath(leaves[k:], index-k), merkleRoot(leaves[:k]))
+}
+
+// largestPowerOfTwoLessThan returns the largest power of two strictly
+// less than n, per the RFC 6962 MTH split point definition.
+//
+func largestPowerOfTwoLessThan(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// verifyInclusionProof reports whether path proves that leaf (the raw
+// DER of a certificate) is the entry at index in a tree of the given
+// size with the given root hash. It retraces the same recursive split
+// merklePath used to build the proof, folding from the leaf up to the
+// root.
+//
+func verifyInclusionProof(leaf []byte, index, treeSize int, root []byte, path [][]byte) bool {
+	computed := rootFromProof(rfc6962LeafHash(leaf), index, treeSize, path)
+
+	return string(computed) == string(root)
+}
+
+func rootFromProof(leafHash []byte, index, n int, path [][]byte) []byte {
+	if n <= 1 {
+		return leafHash
+	}
+
+	k := largestPowerOfTwoLessThan(n)
+	if index < k {
+		sub := rootFromProof(leafHash, index, k, path[:len(path)-1])
+		return rfc6962NodeHash(sub, path[len(path)-1])
+	}
+
+	sub := rootFromProof(leafHash, index-k, n-k, path[:len(path)-1])
+	return rfc6962NodeHash(path[len(path)-1], sub)
+}
+
+func readFull(f *os.File, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := f.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,53 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import "testing"
+
+func TestMerkleInclusionProofTamperedLeaf(t *testing.T) {
+	leaves := [][]byte{
+		[]byte("cert-0"),
+		[]byte("cert-1"),
+		[]byte("cert-2"),
+		[]byte("cert-3"),
+		[]byte("cert-4"),
+	}
+
+	hashes := make([][]byte, len(leaves))
+	for i, l := range leaves {
+		hashes[i] = rfc6962LeafHash(l)
+	}
+
+	root := merkleRoot(hashes)
+
+	const index = 2
+	proof := merklePath(hashes, index)
+
+	if !verifyInclusionProof(leaves[index], index, len(leaves), root, proof) {
+		t.Fatal("valid inclusion proof did not verify")
+	}
+
+	tampered := append([]byte(nil), leaves[index]...)
+	tampered[0] ^= 0xff
+
+	if verifyInclusionProof(tampered, index, len(leaves), root, proof) {
+		t.Fatal("inclusion proof verified against a tampered leaf")
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"errors"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// ECA is the enrollment certificate authority.
+//
+type ECA struct {
+	*CA
+	revocation *revocationStore
+	audit      *auditLog
+}
+
+// ECAP serves the public GRPC interface of the ECA.
+//
+type ECAP struct {
+	eca *ECA
+}
+
+// ECAA serves the administrator GRPC interface of the ECA.
+//
+type ECAA struct {
+	eca *ECA
+}
+
+// NewECA sets up a new ECA.
+//
+func NewECA() *ECA {
+	ca := NewCA("eca")
+
+	revocation, err := newRevocationStore(ca.db, "ECARevocations")
+	if err != nil {
+		Error.Panicln(err)
+	}
+
+	audit, err := newAuditLog(ca.path + "/eca.audit")
+	if err != nil {
+		Error.Panicln(err)
+	}
+
+	eca := &ECA{ca, revocation, audit}
+
+	if _, err := eca.db.Exec("CREATE TABLE IF NOT EXISTS EnrollmentSecrets (id TEXT PRIMARY KEY, secret TEXT)"); err != nil {
+		Error.Panicln(err)
+	}
+
+	return eca
+}
+
+// Start starts the ECA.
+//
+func (eca *ECA) Start(srv *grpc.Server) {
+	eca.startECAP(srv)
+	eca.startECAA(srv)
+
+	Info.Println("ECA started.")
+}
+
+func (eca *ECA) startECAP(srv *grpc.Server) {
+	pb.RegisterECAPServer(srv, &ECAP{eca})
+}
+
+func (eca *ECA) startECAA(srv *grpc.Server) {
+	pb.RegisterECAAServer(srv, &ECAA{eca})
+}
+
+// ReadCACertificate reads the certificate of the ECA.
+//
+func (ecap *ECAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
+	Trace.Println("grpc ECAP:ReadCACertificate")
+
+	return &pb.Cert{ecap.eca.raw}, nil
+}
+
+// CreateCertificate requests the creation of a new enrollment
+// certificate by the ECA, symmetrically with
+// TLSCAP.CreateCertificate: the subject's signature is verified, the
+// certificate is minted, and it is appended to the ECA's own audit log.
+//
+func (ecap *ECAP) CreateCertificate(ctx context.Context, req *pb.ECertCreateReq) (*pb.ECertCreateResp, error) {
+	Trace.Println("grpc ECAP:CreateCertificate")
+
+	id := req.Id.Id
+
+	sig := req.Sig
+	req.Sig = nil
+
+	pub, err := x509.ParsePKIXPublicKey(req.Pub.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySubjectSignature(req.Pub.Type, pub, sig, req); err != nil {
+		return nil, err
+	}
+
+	// x509.ECDSAWithSHA384 describes how the ECA's own (ECDSA) key signs
+	// the issued certificate, not the subject's key type, so it never
+	// varies with req.Pub.Type.
+	raw, err := ecap.eca.createCertificate(id, pub, x509.KeyUsageDigitalSignature, req.Ts.Seconds, x509.ECDSAWithSHA384)
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	if err := ecap.eca.audit.append(raw); err != nil {
+		Error.Println(err)
+	}
+
+	return &pb.ECertCreateResp{&pb.Cert{raw}}, nil
+}
+
+// registerEnrollmentSecret records the secret an administrator hands out
+// when pre-authorizing id to enroll, so later enrollment requests
+// (including SCEP challenge passwords) can be checked against it. The
+// secret stays valid until it is replaced; checking it does not consume
+// it.
+//
+func (eca *ECA) registerEnrollmentSecret(id, secret string) error {
+	_, err := eca.db.Exec("INSERT OR REPLACE INTO EnrollmentSecrets (id, secret) VALUES (?, ?)", id, secret)
+
+	return err
+}
+
+// checkEnrollmentSecret reports whether secret matches the enrollment
+// secret registered for id.
+//
+func (eca *ECA) checkEnrollmentSecret(id, secret string) error {
+	row := eca.db.QueryRow("SELECT secret FROM EnrollmentSecrets WHERE id = ?", id)
+
+	var stored string
+	if err := row.Scan(&stored); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("no enrollment secret registered for id")
+		}
+		return err
+	}
+
+	if stored != secret {
+		return errors.New("enrollment secret does not match")
+	}
+
+	return nil
+}
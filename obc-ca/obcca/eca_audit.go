@@ -0,0 +1,63 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"errors"
+	"math/big"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"golang.org/x/net/context"
+)
+
+// GetSTH returns the ECA's current signed tree head over its
+// certificate audit log.
+//
+func (ecap *ECAP) GetSTH(ctx context.Context, in *pb.Empty) (*pb.STH, error) {
+	Trace.Println("grpc ECAP:GetSTH")
+
+	sth, err := ecap.eca.audit.sth(ecap.eca.priv)
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.STH{sth.TreeSize, sth.RootHash, sth.Timestamp, sth.Sig}, nil
+}
+
+// GetInclusionProof returns the Merkle audit path proving that the
+// enrollment certificate with the given serial number is included in
+// the tree of size req.TreeSize.
+//
+func (ecap *ECAP) GetInclusionProof(ctx context.Context, req *pb.InclusionProofReq) (*pb.InclusionProof, error) {
+	Trace.Println("grpc ECAP:GetInclusionProof")
+
+	serial, ok := new(big.Int).SetString(req.Serial, 10)
+	if !ok {
+		return nil, errors.New("invalid serial number")
+	}
+
+	path, err := ecap.eca.audit.inclusionProof(serial, req.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.InclusionProof{path}, nil
+}
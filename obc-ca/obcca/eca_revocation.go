@@ -0,0 +1,124 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"golang.org/x/crypto/ocsp"
+	"golang.org/x/net/context"
+)
+
+// RevokeCertificate revokes an enrollment certificate from the ECA after
+// verifying that req was signed by a registered administrator's
+// enrollment key.
+//
+func (ecaa *ECAA) RevokeCertificate(ctx context.Context, req *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+	Trace.Println("grpc ECAA:RevokeCertificate")
+
+	sig := req.Sig
+	req.Sig = nil
+
+	readAdminCert := func(id string) ([]byte, error) {
+		return ecaa.eca.readCertificate(id, x509.KeyUsageDigitalSignature)
+	}
+	if err := verifyAdminSignature(readAdminCert, req.Id.Id, sig, req); err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(req.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ecaa.eca.revocation.revoke(cert.SerialNumber, int(req.Reason)); err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.CAStatus{pb.CAStatus_OK}, nil
+}
+
+// GetCRL returns a freshly signed x509 CRL covering every enrollment
+// certificate the ECA has revoked so far.
+//
+func (ecap *ECAP) GetCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc ECAP:GetCRL")
+
+	revoked, err := ecap.eca.revocation.all()
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	caCert, err := x509.ParseCertificate(ecap.eca.raw)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := buildCRL(caCert, ecap.eca.priv, revoked, defaultCRLValidity)
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.CRL{der}, nil
+}
+
+// StartOCSPResponder mounts an RFC 6960 OCSP responder for the ECA at
+// path on mux, reusing the same logic the TLSCA uses.
+//
+func (eca *ECA) StartOCSPResponder(mux *http.ServeMux, path string) {
+	mux.HandleFunc(path, eca.ocspHandler)
+
+	Info.Println("ECA OCSP responder started.")
+}
+
+func (eca *ECA) ocspHandler(w http.ResponseWriter, r *http.Request) {
+	der, err := readOCSPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ocspReq, err := ocsp.ParseRequest(der)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caCert, err := x509.ParseCertificate(eca.raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := buildOCSPResponse(ocspReq, caCert, caCert, eca.priv, eca.revocation)
+	if err != nil {
+		Error.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
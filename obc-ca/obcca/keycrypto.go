@@ -0,0 +1,120 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	// sha3's init registers crypto.SHA3_384 for use below.
+	"golang.org/x/crypto/sha3"
+)
+
+// verifySubjectSignature checks that req was signed by the private key
+// matching pub, per the conventions of keyType. req's own Sig field
+// must already be cleared by the caller before it is marshaled here.
+// It only attests to possession of the subject's private key; it says
+// nothing about how the issued certificate itself should be signed,
+// since that is determined by the CA's own (fixed ECDSA) issuing key,
+// not the subject's. It is shared by TLSCAP.CreateCertificate,
+// ECAP.CreateCertificate, and the admin-signature checks in
+// revocation.go.
+//
+func verifySubjectSignature(keyType pb.CryptoType, pub interface{}, sig *pb.Signature, req proto.Message) error {
+	switch keyType {
+	case pb.CryptoType_ECDSA:
+		key, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("public key does not match declared type ECDSA")
+		}
+
+		r, s := big.NewInt(0), big.NewInt(0)
+		r.UnmarshalText(sig.R)
+		s.UnmarshalText(sig.S)
+
+		if !ecdsa.Verify(key, digestRequest(req), r, s) {
+			return errors.New("signature does not verify")
+		}
+
+		return nil
+
+	case pb.CryptoType_RSA_PSS:
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("public key does not match declared type RSA")
+		}
+
+		digest := digestRequest(req)
+		if err := rsa.VerifyPSS(key, crypto.SHA3_384, digest, sig.R, nil); err != nil {
+			return errors.New("signature does not verify")
+		}
+
+		return nil
+
+	case pb.CryptoType_RSA_PKCS1V15:
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("public key does not match declared type RSA")
+		}
+
+		digest := digestRequest(req)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA3_384, digest, sig.R); err != nil {
+			return errors.New("signature does not verify")
+		}
+
+		return nil
+
+	case pb.CryptoType_ED25519:
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("public key does not match declared type ED25519")
+		}
+
+		// Ed25519 hashes internally, so it signs the raw marshaled
+		// request rather than a pre-computed digest.
+		raw, _ := proto.Marshal(req)
+		if !ed25519.Verify(key, raw, sig.R) {
+			return errors.New("signature does not verify")
+		}
+
+		return nil
+
+	default:
+		return errors.New("unsupported key type")
+	}
+}
+
+// digestRequest returns the SHA3-384 digest of the marshaled request,
+// used as the signed payload for hash-then-sign schemes (ECDSA, RSA).
+//
+func digestRequest(req proto.Message) []byte {
+	raw, _ := proto.Marshal(req)
+
+	hash := sha3.New384()
+	hash.Write(raw)
+
+	return hash.Sum(nil)
+}
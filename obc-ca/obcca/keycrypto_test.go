@@ -0,0 +1,178 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+)
+
+func TestVerifySubjectSignatureRSAPKCS1v15(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &pb.TLSCertCreateReq{Id: &pb.Identity{Id: "device-1"}}
+	digest := digestRequest(req)
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA3_384, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := &pb.Signature{R: signature}
+
+	if err := verifySubjectSignature(pb.CryptoType_RSA_PKCS1V15, &key.PublicKey, sig, req); err != nil {
+		t.Fatalf("valid RSA PKCS1v15 signature did not verify: %v", err)
+	}
+
+	sig.R[0] ^= 0xff
+	if err := verifySubjectSignature(pb.CryptoType_RSA_PKCS1V15, &key.PublicKey, sig, req); err == nil {
+		t.Fatal("tampered RSA signature verified")
+	}
+}
+
+func TestVerifySubjectSignatureRSAPSS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &pb.TLSCertCreateReq{Id: &pb.Identity{Id: "device-2"}}
+	digest := digestRequest(req)
+
+	signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA3_384, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := &pb.Signature{R: signature}
+
+	if err := verifySubjectSignature(pb.CryptoType_RSA_PSS, &key.PublicKey, sig, req); err != nil {
+		t.Fatalf("valid RSA PSS signature did not verify: %v", err)
+	}
+
+	sig.R[0] ^= 0xff
+	if err := verifySubjectSignature(pb.CryptoType_RSA_PSS, &key.PublicKey, sig, req); err == nil {
+		t.Fatal("tampered RSA signature verified")
+	}
+}
+
+func TestVerifySubjectSignatureEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &pb.TLSCertCreateReq{Id: &pb.Identity{Id: "device-3"}}
+	raw, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := &pb.Signature{R: ed25519.Sign(priv, raw)}
+
+	if err := verifySubjectSignature(pb.CryptoType_ED25519, pub, sig, req); err != nil {
+		t.Fatalf("valid Ed25519 signature did not verify: %v", err)
+	}
+
+	sig.R[0] ^= 0xff
+	if err := verifySubjectSignature(pb.CryptoType_ED25519, pub, sig, req); err == nil {
+		t.Fatal("tampered Ed25519 signature verified")
+	}
+}
+
+// TestCreateCertificateSignatureAlgorithmMatchesIssuer checks, against
+// x509.CreateCertificate directly, the setting TLSCAP.CreateCertificate
+// and ECAP.CreateCertificate pass to createCertificate: SignatureAlgorithm
+// describes how the issuer signs the certificate, not the subject's key
+// type. The CA's issuing key in this repo is always ECDSA (see
+// auditLog.sth), so a certificate signed with x509.ECDSAWithSHA384 by an
+// ECDSA CA key must issue and verify whether it carries an ECDSA, RSA,
+// or Ed25519 subject key.
+func TestCreateCertificateSignatureAlgorithmMatchesIssuer(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subjects := []interface{}{&rsaKey.PublicKey, ed25519Pub, &caKey.PublicKey}
+	for _, pub := range subjects {
+		template := &x509.Certificate{
+			SerialNumber:       big.NewInt(2),
+			Subject:            pkix.Name{CommonName: "device"},
+			NotBefore:          time.Now(),
+			NotAfter:           time.Now().Add(time.Hour),
+			SignatureAlgorithm: x509.ECDSAWithSHA384,
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, pub, caKey)
+		if err != nil {
+			t.Fatalf("CreateCertificate with issuer-matching SignatureAlgorithm failed for subject key %T: %v", pub, err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cert.CheckSignatureFrom(caCert); err != nil {
+			t.Fatalf("issued certificate does not verify against the CA: %v", err)
+		}
+	}
+}
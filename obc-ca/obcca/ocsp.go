@@ -0,0 +1,85 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// StartOCSPResponder mounts an RFC 6960 OCSP responder for the TLSCA at
+// path on mux. The CA's own certificate and key are used to sign
+// responses.
+//
+func (tlsca *TLSCA) StartOCSPResponder(mux *http.ServeMux, path string) {
+	mux.HandleFunc(path, tlsca.ocspHandler)
+
+	Info.Println("TLSCA OCSP responder started.")
+}
+
+func (tlsca *TLSCA) ocspHandler(w http.ResponseWriter, r *http.Request) {
+	der, err := readOCSPRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ocspReq, err := ocsp.ParseRequest(der)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caCert, err := x509.ParseCertificate(tlsca.raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := buildOCSPResponse(ocspReq, caCert, caCert, tlsca.priv, tlsca.revocation)
+	if err != nil {
+		Error.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+// readOCSPRequest extracts the DER-encoded OCSPRequest from an HTTP
+// request, supporting both the GET (base64 path segment) and POST
+// (raw body) transports defined by RFC 6960.
+//
+func readOCSPRequest(r *http.Request) ([]byte, error) {
+	if r.Method == http.MethodPost {
+		return ioutil.ReadAll(r.Body)
+	}
+
+	segments := strings.Split(strings.TrimRight(r.URL.Path, "/"), "/")
+	encoded := segments[len(segments)-1]
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
@@ -0,0 +1,248 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"golang.org/x/crypto/ocsp"
+)
+
+// revokedCert is a single entry in a CA's revocation store.
+//
+type revokedCert struct {
+	serial     *big.Int
+	reasonCode int
+	revokedAt  time.Time
+}
+
+// revocationStore is an append-style table of revoked certificate serials
+// shared by the CAs that embed it (TLSCA, ECA). It is backed by the CA's
+// sqlite database so that revocations survive a restart.
+//
+type revocationStore struct {
+	db    *sql.DB
+	table string
+}
+
+// newRevocationStore creates the revocation table in db, if it does not
+// already exist, and returns a store backed by it.
+//
+func newRevocationStore(db *sql.DB, table string) (*revocationStore, error) {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + table + " (serial TEXT, reason INTEGER, revoked_at INTEGER)")
+	if err != nil {
+		return nil, err
+	}
+
+	return &revocationStore{db, table}, nil
+}
+
+// revoke records serial as revoked with the given CRL reason code.
+//
+func (rs *revocationStore) revoke(serial *big.Int, reasonCode int) error {
+	_, err := rs.db.Exec("INSERT INTO "+rs.table+" (serial, reason, revoked_at) VALUES (?, ?, ?)",
+		serial.String(), reasonCode, time.Now().Unix())
+
+	return err
+}
+
+// lookup returns the revocation entry for serial, or nil if serial has not
+// been revoked.
+//
+func (rs *revocationStore) lookup(serial *big.Int) (*revokedCert, error) {
+	row := rs.db.QueryRow("SELECT reason, revoked_at FROM "+rs.table+" WHERE serial = ?", serial.String())
+
+	var reason int
+	var revokedAt int64
+	if err := row.Scan(&reason, &revokedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &revokedCert{serial, reason, time.Unix(revokedAt, 0)}, nil
+}
+
+// all returns every entry currently in the revocation store.
+//
+func (rs *revocationStore) all() ([]*revokedCert, error) {
+	rows, err := rs.db.Query("SELECT serial, reason, revoked_at FROM " + rs.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked []*revokedCert
+	for rows.Next() {
+		var serialStr string
+		var reason int
+		var revokedAt int64
+		if err := rows.Scan(&serialStr, &reason, &revokedAt); err != nil {
+			return nil, err
+		}
+
+		serial, ok := new(big.Int).SetString(serialStr, 10)
+		if !ok {
+			continue
+		}
+		revoked = append(revoked, &revokedCert{serial, reason, time.Unix(revokedAt, 0)})
+	}
+
+	return revoked, nil
+}
+
+// crlReasonToOCSP maps an RFC 5280 CRL reason code onto the subset of
+// reasons the OCSP responder is willing to report.
+//
+func crlReasonToOCSP(reasonCode int) int {
+	switch reasonCode {
+	case int(ocsp.KeyCompromise), int(ocsp.CACompromise), int(ocsp.Superseded), int(ocsp.CessationOfOperation):
+		return reasonCode
+	default:
+		return int(ocsp.Unspecified)
+	}
+}
+
+// buildCRL builds and signs a DER-encoded x509 CRL covering revoked, using
+// caCert/caPriv as the issuer and nextUpdate as the CRL's NextUpdate field.
+//
+func buildCRL(caCert *x509.Certificate, caPriv interface{}, revoked []*revokedCert, nextUpdate time.Duration) ([]byte, error) {
+	now := time.Now()
+
+	entries := make([]pkix.RevokedCertificate, 0, len(revoked))
+	for _, r := range revoked {
+		entries = append(entries, pkix.RevokedCertificate{
+			SerialNumber:   r.serial,
+			RevocationTime: r.revokedAt,
+		})
+	}
+
+	return caCert.CreateCRL(rand.Reader, caPriv, entries, now, now.Add(nextUpdate))
+}
+
+// buildOCSPResponse looks req's serial up in rs and returns a signed OCSP
+// response reflecting its status: Unknown when req names an issuer key
+// other than caCert's, Revoked when rs holds the serial, Good otherwise.
+//
+func buildOCSPResponse(req *ocsp.Request, caCert, responderCert *x509.Certificate, responderKey interface{}, rs *revocationStore) ([]byte, error) {
+	status := ocsp.Good
+	var revokedAt time.Time
+	var reason int
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(caCert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, err
+	}
+	issuerMatches := req.HashAlgorithm.Available()
+	if issuerMatches {
+		hash := req.HashAlgorithm.New()
+		hash.Write(spki.PublicKey.RightAlign())
+		issuerMatches = bytes.Equal(hash.Sum(nil), req.IssuerKeyHash)
+	}
+
+	entry, err := rs.lookup(req.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !issuerMatches {
+		status = ocsp.Unknown
+	} else if entry != nil {
+		status = ocsp.Revoked
+		revokedAt = entry.revokedAt
+		reason = crlReasonToOCSP(entry.reasonCode)
+	}
+
+	template := ocsp.Response{
+		Status:           status,
+		SerialNumber:     req.SerialNumber,
+		ThisUpdate:       time.Now(),
+		NextUpdate:       time.Now().Add(defaultOCSPValidity),
+		RevokedAt:        revokedAt,
+		RevocationReason: reason,
+		Certificate:      responderCert,
+	}
+
+	return ocsp.CreateResponse(caCert, responderCert, template, responderKey)
+}
+
+// verifyAdminSignature checks that sig is a valid signature by id's
+// enrollment key over req (with req's own Sig field already cleared by
+// the caller), fetching id's certificate via readAdminCert. Enrollment
+// certificates may carry an ECDSA, RSA, or Ed25519 key (see
+// verifySubjectSignature), so the admin's key type is taken from the
+// certificate itself rather than assumed to be ECDSA. The revocation
+// request has no field declaring which RSA scheme was used, so an RSA
+// admin key is checked against PKCS1v15 first and PSS second; both
+// verifiers return a plain error rather than panicking on a mismatch,
+// so trying both is safe. It is shared by TLSCAA.RevokeCertificate and
+// ECAA.RevokeCertificate so both CAs authorize administrator requests
+// the same way.
+//
+func verifyAdminSignature(readAdminCert func(id string) ([]byte, error), id string, sig *pb.Signature, req proto.Message) error {
+	adminRaw, err := readAdminCert(id)
+	if err != nil {
+		return err
+	}
+	adminCert, err := x509.ParseCertificate(adminRaw)
+	if err != nil {
+		return err
+	}
+
+	switch adminCert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return verifySubjectSignature(pb.CryptoType_ECDSA, adminCert.PublicKey, sig, req)
+
+	case ed25519.PublicKey:
+		return verifySubjectSignature(pb.CryptoType_ED25519, adminCert.PublicKey, sig, req)
+
+	case *rsa.PublicKey:
+		if err := verifySubjectSignature(pb.CryptoType_RSA_PKCS1V15, adminCert.PublicKey, sig, req); err == nil {
+			return nil
+		}
+		return verifySubjectSignature(pb.CryptoType_RSA_PSS, adminCert.PublicKey, sig, req)
+
+	default:
+		return errors.New("unsupported administrator key type")
+	}
+}
+
+// defaultCRLValidity and defaultOCSPValidity bound how long a CRL or OCSP
+// response may be cached by a relying party before it must be refreshed.
+const (
+	defaultCRLValidity  = 24 * time.Hour
+	defaultOCSPValidity = 1 * time.Hour
+)
@@ -0,0 +1,255 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fullsailor/pkcs7"
+)
+
+// SCEPChallengeValidator checks the challenge password carried in a SCEP
+// enrollment request for id. Implementations back it by whatever secret
+// store the deployment uses to pre-authorize enrollments.
+//
+type SCEPChallengeValidator interface {
+	Validate(id, challenge string) bool
+}
+
+// ecaChallengeValidator is the default SCEPChallengeValidator, backed by
+// the enrollment secrets the ECA already hands out to registered
+// identities.
+//
+type ecaChallengeValidator struct {
+	eca *ECA
+}
+
+// Validate reports whether challenge is the enrollment secret the ECA
+// issued to id.
+//
+func (v *ecaChallengeValidator) Validate(id, challenge string) bool {
+	return v.eca.checkEnrollmentSecret(id, challenge) == nil
+}
+
+// SCEPConfig holds the operator-tunable knobs for the TLSCA's SCEP
+// endpoint.
+//
+type SCEPConfig struct {
+	// Validator authorizes PKIOperation enrollment requests.
+	Validator SCEPChallengeValidator
+
+	// SigningAlgorithm and EncryptionAlgorithm are advertised via
+	// GetCACaps. PKIOperation does not consult them: decryption and
+	// signing use the pkcs7 package's own algorithm choices.
+	SigningAlgorithm    string
+	EncryptionAlgorithm string
+}
+
+// defaultSCEPConfig returns the SCEPConfig used when StartSCEP is called
+// without an explicit one.
+//
+func defaultSCEPConfig(eca *ECA) *SCEPConfig {
+	return &SCEPConfig{
+		Validator:           &ecaChallengeValidator{eca},
+		SigningAlgorithm:    "SHA384",
+		EncryptionAlgorithm: "AES256",
+	}
+}
+
+// StartSCEP mounts a SCEP-over-HTTP enrollment endpoint for the TLSCA at
+// path on mux, so clients that only speak SCEP (network devices, IoT)
+// can enroll for a TLS certificate. cfg may be nil to use the defaults.
+//
+func (tlsca *TLSCA) StartSCEP(mux *http.ServeMux, path string, cfg *SCEPConfig) {
+	if cfg == nil {
+		cfg = defaultSCEPConfig(tlsca.eca)
+	}
+
+	mux.HandleFunc(path, tlsca.scepHandler(cfg))
+
+	Info.Println("TLSCA SCEP endpoint started.")
+}
+
+func (tlsca *TLSCA) scepHandler(cfg *SCEPConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("operation") {
+		case "GetCACaps":
+			tlsca.scepGetCACaps(w, cfg)
+		case "GetCACert":
+			tlsca.scepGetCACert(w)
+		case "PKIOperation":
+			tlsca.scepPKIOperation(w, r, cfg)
+		default:
+			http.Error(w, "unsupported SCEP operation", http.StatusBadRequest)
+		}
+	}
+}
+
+// scepGetCACaps advertises the capabilities this responder supports, per
+// section 3.5.1 of the SCEP draft.
+//
+func (tlsca *TLSCA) scepGetCACaps(w http.ResponseWriter, cfg *SCEPConfig) {
+	caps := []string{"POSTPKIOperation", "Renewal", cfg.SigningAlgorithm, cfg.EncryptionAlgorithm}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(strings.Join(caps, "\n")))
+}
+
+// scepGetCACert returns the TLSCA's own certificate, DER-encoded.
+//
+func (tlsca *TLSCA) scepGetCACert(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Write(tlsca.raw)
+}
+
+// scepPKIOperation handles a PKCS#7-wrapped PKCS#10 enrollment request,
+// validates its challenge password, mints the certificate via the same
+// createCertificate path CreateCertificate uses, and returns the
+// certificate in a CA-signed PKCS#7 message (see scepCertRep).
+//
+func (tlsca *TLSCA) scepPKIOperation(w http.ResponseWriter, r *http.Request, cfg *SCEPConfig) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	envelope, err := pkcs7.Parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	csrDER, err := envelope.Decrypt(tlsca.cert(), tlsca.priv)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := csr.CheckSignature(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, challenge := scepChallengeFromCSR(csr)
+	if !cfg.Validator.Validate(id, challenge) {
+		http.Error(w, "invalid challenge password", http.StatusForbidden)
+		return
+	}
+
+	certDER, err := tlsca.createCertificate(id, csr.PublicKey, x509.KeyUsageKeyAgreement, time.Now().Unix(), x509.ECDSAWithSHA384)
+	if err != nil {
+		Error.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tlsca.audit.append(certDER); err != nil {
+		Error.Println(err)
+	}
+
+	certRep, err := scepCertRep(envelope, cert, tlsca.cert(), tlsca.priv)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pki-message")
+	w.Write(certRep)
+}
+
+// scepChallengeFromCSR extracts the CommonName and the SCEP challenge
+// password attribute carried in csr. The challengePassword attribute is
+// a plain DirectoryString, not the SET-of-AttributeTypeAndValue shape
+// pkix.AttributeTypeAndValueSET assumes (that shape only fits
+// extensionRequest), so the raw attribute bytes are decoded directly.
+//
+func scepChallengeFromCSR(csr *x509.CertificateRequest) (id, challenge string) {
+	id = csr.Subject.CommonName
+
+	for _, raw := range csr.RawAttributes {
+		var attr struct {
+			Type  asn1.ObjectIdentifier
+			Value []asn1.RawValue `asn1:"set"`
+		}
+		if _, err := asn1.Unmarshal(raw.FullBytes, &attr); err != nil {
+			continue
+		}
+		if attr.Type.String() != scepChallengePasswordOID || len(attr.Value) == 0 {
+			continue
+		}
+
+		var s string
+		if _, err := asn1.Unmarshal(attr.Value[0].FullBytes, &s); err == nil {
+			challenge = s
+		}
+	}
+
+	return id, challenge
+}
+
+// scepChallengePasswordOID is the PKCS#9 challengePassword attribute OID
+// carried in a PKCS#10 CSR's attributes.
+const scepChallengePasswordOID = "1.2.840.113549.1.9.7"
+
+// scepCertRep returns cert's DER wrapped in a PKCS#7 SignedData signed
+// with caCert/caPriv. The content is not encrypted to the requester, and
+// req is not read, so no SCEP message attributes (transaction ID, nonces,
+// pkiStatus) are carried over into the reply.
+//
+func scepCertRep(req *pkcs7.PKCS7, cert, caCert *x509.Certificate, caPriv interface{}) ([]byte, error) {
+	signedData, err := pkcs7.NewSignedData(cert.Raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := signedData.AddSigner(caCert, caPriv, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+
+	return signedData.Finish()
+}
+
+func (tlsca *TLSCA) cert() *x509.Certificate {
+	cert, err := x509.ParseCertificate(tlsca.raw)
+	if err != nil {
+		Error.Println(err)
+		return nil
+	}
+
+	return cert
+}
+
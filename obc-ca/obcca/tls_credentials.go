@@ -0,0 +1,171 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// reloadingCertificate loads a certificate/key pair from disk and
+// transparently re-reads both whenever the certificate file's mtime
+// advances (a change to the key file alone is not noticed), so long-lived
+// servers can pick up rotated TLSCA-issued certs without a restart.
+//
+type reloadingCertificate struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newReloadingCertificate(certFile, keyFile string) (*reloadingCertificate, error) {
+	rc := &reloadingCertificate{certFile: certFile, keyFile: keyFile}
+	if err := rc.reload(); err != nil {
+		return nil, err
+	}
+
+	go rc.watch()
+
+	return rc, nil
+}
+
+func (rc *reloadingCertificate) reload() error {
+	info, err := os.Stat(rc.certFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(rc.certFile, rc.keyFile)
+	if err != nil {
+		return err
+	}
+
+	rc.mutex.Lock()
+	rc.cert = &cert
+	rc.modTime = info.ModTime()
+	rc.mutex.Unlock()
+
+	return nil
+}
+
+// watch polls certFile's mtime and reloads the pair whenever it changes.
+// Polling keeps this dependency-free; an fsnotify watch could replace it
+// on platforms where that is preferred.
+//
+func (rc *reloadingCertificate) watch() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(rc.certFile)
+		if err != nil {
+			Error.Println(err)
+			continue
+		}
+
+		rc.mutex.RLock()
+		changed := info.ModTime().After(rc.modTime)
+		rc.mutex.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err := rc.reload(); err != nil {
+			Error.Println(err)
+		}
+	}
+}
+
+func (rc *reloadingCertificate) get() *tls.Certificate {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+
+	return rc.cert
+}
+
+// NewServerCredentials builds gRPC transport credentials for a CA server
+// that hot-reloads certFile/keyFile from disk and requires and verifies a
+// client certificate against the pool in clientCAFile.
+//
+func NewServerCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, error) {
+	rc, err := newReloadingCertificate(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCAs, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return rc.get(), nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return nil, nil
+		},
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	raw, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, errors.New("failed to parse client CA certificate")
+	}
+
+	return pool, nil
+}
+
+// StartSecure starts the TLSCA on lis with the given transport
+// credentials, so that clients must present a certificate verified
+// against creds' client CA pool (typically the ECA's) before calling
+// CreateCertificate, and operators can rotate the TLSCA's own server
+// cert by replacing the files NewServerCredentials was pointed at.
+//
+func (tlsca *TLSCA) StartSecure(lis net.Listener, creds credentials.TransportCredentials) error {
+	srv := grpc.NewServer(grpc.Creds(creds))
+
+	tlsca.Start(srv)
+
+	return srv.Serve(lis)
+}
@@ -20,14 +20,10 @@ under the License.
 package obcca
 
 import (
-	"crypto/ecdsa"
 	"crypto/x509"
 	"errors"
-	"math/big"
 
-	"github.com/golang/protobuf/proto"
 	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
-	"golang.org/x/crypto/sha3"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
@@ -36,7 +32,9 @@ import (
 //
 type TLSCA struct {
 	*CA
-	eca     *ECA
+	eca        *ECA
+	revocation *revocationStore
+	audit      *auditLog
 }
 
 // TLSCAP serves the public GRPC interface of the TLSCA.
@@ -54,7 +52,19 @@ type TLSCAA struct {
 // NewTLSCA sets up a new TLSCA.
 //
 func NewTLSCA(eca *ECA) *TLSCA {
-	tlsca := &TLSCA{NewCA("tlsca"), eca}
+	ca := NewCA("tlsca")
+
+	revocation, err := newRevocationStore(ca.db, "TLSCARevocations")
+	if err != nil {
+		Error.Panicln(err)
+	}
+
+	audit, err := newAuditLog(ca.path + "/tlsca.audit")
+	if err != nil {
+		Error.Panicln(err)
+	}
+
+	tlsca := &TLSCA{ca, eca, revocation, audit}
 
 	return tlsca
 }
@@ -95,31 +105,28 @@ func (tlscap *TLSCAP) CreateCertificate(ctx context.Context, req *pb.TLSCertCrea
 	sig := req.Sig
 	req.Sig = nil
 
-	r, s := big.NewInt(0), big.NewInt(0)
-	r.UnmarshalText(sig.R)
-	s.UnmarshalText(sig.S)
-
-	raw := req.Pub.Key
-	if req.Pub.Type != pb.CryptoType_ECDSA {
-		return nil, errors.New("unsupported key type")
-	}
 	pub, err := x509.ParsePKIXPublicKey(req.Pub.Key)
 	if err != nil {
 		return nil, err
 	}
 
-	hash := sha3.New384()
-	raw, _ = proto.Marshal(req)
-	hash.Write(raw)
-	if ecdsa.Verify(pub.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
-		return nil, errors.New("signature does not verify")
+	if err := verifySubjectSignature(req.Pub.Type, pub, sig, req); err != nil {
+		return nil, err
 	}
 
-	if raw, err = tlscap.tlsca.createCertificate(id, pub.(*ecdsa.PublicKey), x509.KeyUsageKeyAgreement, req.Ts.Seconds); err != nil {
+	// x509.ECDSAWithSHA384 describes how the TLSCA's own (ECDSA) key
+	// signs the issued certificate, not the subject's key type, so it
+	// never varies with req.Pub.Type.
+	raw, err := tlscap.tlsca.createCertificate(id, pub, x509.KeyUsageKeyAgreement, req.Ts.Seconds, x509.ECDSAWithSHA384)
+	if err != nil {
 		Error.Println(err)
 		return nil, err
 	}
 
+	if err := tlscap.tlsca.audit.append(raw); err != nil {
+		Error.Println(err)
+	}
+
 	return &pb.TLSCertCreateResp{&pb.Cert{raw}}, nil
 }
 
@@ -136,18 +143,66 @@ func (tlscap *TLSCAP) ReadCertificate(ctx context.Context, req *pb.TLSCertReadRe
 	return &pb.Cert{raw}, nil
 }
 
-// RevokeCertificate revokes a certificate from the TLSCA.  Not yet implemented.
+// RevokeCertificate revokes a certificate from the TLSCA.  Only the TLSCAA
+// interface can revoke certificates; use it instead.
 //
 func (tlscap *TLSCAP) RevokeCertificate(context.Context, *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TLSCAP:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	return nil, errors.New("revocation requires the TLSCAA administrator interface")
 }
 
-// RevokeCertificate revokes a certificate from the TLSCA.  Not yet implemented.
+// RevokeCertificate revokes a certificate from the TLSCA after verifying
+// that req was signed by a registered administrator's enrollment key.
 //
-func (tlscaa *TLSCAA) RevokeCertificate(context.Context, *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
+func (tlscaa *TLSCAA) RevokeCertificate(ctx context.Context, req *pb.TLSCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TLSCAA:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	sig := req.Sig
+	req.Sig = nil
+
+	readAdminCert := func(id string) ([]byte, error) {
+		return tlscaa.tlsca.eca.readCertificate(id, x509.KeyUsageDigitalSignature)
+	}
+	if err := verifyAdminSignature(readAdminCert, req.Id.Id, sig, req); err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(req.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tlscaa.tlsca.revocation.revoke(cert.SerialNumber, int(req.Reason)); err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.CAStatus{pb.CAStatus_OK}, nil
+}
+
+// GetCRL returns a freshly signed x509 CRL covering every certificate the
+// TLSCA has revoked so far.
+//
+func (tlscap *TLSCAP) GetCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc TLSCAP:GetCRL")
+
+	revoked, err := tlscap.tlsca.revocation.all()
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	caCert, err := x509.ParseCertificate(tlscap.tlsca.raw)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := buildCRL(caCert, tlscap.tlsca.priv, revoked, defaultCRLValidity)
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.CRL{der}, nil
 }
\ No newline at end of file